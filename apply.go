@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/FranGM/simplelog"
+	"golang.org/x/sync/errgroup"
+)
+
+// applyResult is the outcome of applying a single dotfile, reported back over a channel so
+// applyAll can render progress as workers finish out of order.
+type applyResult struct {
+	target  string
+	skipped bool
+	err     error
+}
+
+// dirLocks serializes operations that touch the same parent directory (like RemoveAll, MkdirAll
+// and Symlink in replaceFile), so concurrent workers applying files into the same directory
+// don't race creating or tearing it down. Files whose parent directories differ are not
+// serialized against each other, even when one directory is nested inside the other.
+type dirLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newDirLocks() *dirLocks {
+	return &dirLocks{locks: make(map[string]*sync.Mutex)}
+}
+
+func (d *dirLocks) lock(dir string) func() {
+	d.mu.Lock()
+	l, ok := d.locks[dir]
+	if !ok {
+		l = &sync.Mutex{}
+		d.locks[dir] = l
+	}
+	d.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// applyAll applies every dotfile in dfo.dotfiles concurrently, using dfo.config.Parallelism
+// workers. It reports progress for each file as workers finish, and cancels remaining work as
+// soon as any file fails.
+func (dfo *dfoState) applyAll(facts map[string]interface{}) error {
+	parallelism := dfo.config.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	total := len(dfo.dotfiles)
+	jobs := make(chan dotfileDef)
+	results := make(chan applyResult)
+	locks := newDirLocks()
+
+	g, ctx := errgroup.WithContext(context.Background())
+
+	for i := 0; i < parallelism; i++ {
+		g.Go(func() error {
+			for file := range jobs {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				res := dfo.applyOne(file, facts, locks)
+				select {
+				case results <- res:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				if res.err != nil {
+					return res.err
+				}
+			}
+			return nil
+		})
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, file := range dfo.dotfiles {
+			select {
+			case jobs <- file:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		g.Wait()
+		close(results)
+	}()
+
+	n := 0
+	for res := range results {
+		n++
+		switch {
+		case res.err != nil:
+			simplelog.Info.Printf("[%d/%d] FAILED %q: %v", n, total, res.target, res.err)
+		case res.skipped:
+			simplelog.Debug.Printf("[%d/%d] No changes needed for %v", n, total, res.target)
+		default:
+			simplelog.Info.Printf("[%d/%d] Updated %v", n, total, res.target)
+		}
+	}
+
+	return g.Wait()
+}
+
+// applyOne renders (if templated), backs up, symlinks and runs the hooks for a single dotfile.
+func (dfo *dfoState) applyOne(file dotfileDef, facts map[string]interface{}, locks *dirLocks) applyResult {
+	src := file.src
+	if file.template {
+		renderedPath, err := dfo.renderTemplate(file.dst, file.src, facts)
+		if err != nil {
+			return applyResult{target: file.dst, err: err}
+		}
+		src = renderedPath
+	}
+
+	if file.mode != 0 {
+		if file.template {
+			// The rendered file is already dfo-owned, just chmod it to the requested mode.
+			if !dfo.config.Noop {
+				if err := os.Chmod(src, file.mode); err != nil {
+					return applyResult{target: file.dst, err: err}
+				}
+			}
+		} else {
+			// mode must never be applied to the git-tracked source file back in RepoDir, so give
+			// it a dfo-owned copy to chmod and symlink instead.
+			ownedPath, err := dfo.copyOwned(file.dst, file.src, file.mode)
+			if err != nil {
+				return applyResult{target: file.dst, err: err}
+			}
+			src = ownedPath
+		}
+	}
+
+	needsUpdate, err := fileNeedsUpdating(file.dst, src, dfo.config)
+	if err != nil {
+		return applyResult{target: file.dst, err: err}
+	}
+	if !needsUpdate {
+		dfo.markManaged(file.dst)
+		return applyResult{target: file.dst, skipped: true}
+	}
+
+	if err := dfo.hookErr(file.dst, "before", dfo.runHook(file.before, file.dst, src)); err != nil {
+		return applyResult{target: file.dst, err: err}
+	}
+
+	unlock := locks.lock(filepath.Dir(filepath.Join(dfo.config.HomeDir, file.dst)))
+	err = dfo.replaceFile(file.dst, src)
+	unlock()
+	if err != nil {
+		return applyResult{target: file.dst, err: err}
+	}
+
+	if err := dfo.hookErr(file.dst, "after", dfo.runHook(file.after, file.dst, src)); err != nil {
+		return applyResult{target: file.dst, err: err}
+	}
+
+	return applyResult{target: file.dst}
+}
+
+// hookErr turns a before/after hook failure into an error that aborts the run, unless
+// --continue-on-hook-error was passed, in which case it's logged and swallowed.
+func (dfo *dfoState) hookErr(target, when string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if dfo.config.ContinueOnHookError {
+		simplelog.Info.Printf("%s hook for %q failed: %v (continuing)", when, target, err)
+		return nil
+	}
+	return fmt.Errorf("%s hook for %q failed: %s", when, target, err)
+}
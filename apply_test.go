@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyOneTemplateWithMode(t *testing.T) {
+	repoDir := t.TempDir()
+	srcPath := filepath.Join(repoDir, "tmpl")
+	if err := ioutil.WriteFile(srcPath, []byte("hello {{.host}}"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	dfo := &dfoState{config: dfoConfig{
+		RepoDir: repoDir,
+		WorkDir: t.TempDir(),
+		HomeDir: t.TempDir(),
+	}}
+
+	file := dotfileDef{src: "tmpl", dst: "out", template: true, mode: 0600}
+	facts := map[string]interface{}{"host": "myhost"}
+
+	res := dfo.applyOne(file, facts, newDirLocks())
+	if res.err != nil {
+		t.Fatalf("applyOne: %v", res.err)
+	}
+	if res.skipped {
+		t.Fatalf("applyOne reported skipped on first run")
+	}
+
+	renderedPath := filepath.Join(dfo.renderedDir(), "out")
+	fi, err := os.Stat(renderedPath)
+	if err != nil {
+		t.Fatalf("Stat(rendered): %v", err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("rendered file mode = %v, want 0600 (template: true, mode: 0600 must both apply)", fi.Mode().Perm())
+	}
+
+	got, err := ioutil.ReadFile(renderedPath)
+	if err != nil {
+		t.Fatalf("ReadFile(rendered): %v", err)
+	}
+	if string(got) != "hello myhost" {
+		t.Fatalf("rendered contents = %q, want %q", got, "hello myhost")
+	}
+
+	targetPath := filepath.Join(dfo.config.HomeDir, "out")
+	linkTarget, err := os.Readlink(targetPath)
+	if err != nil {
+		t.Fatalf("Readlink: %v", err)
+	}
+	if linkTarget != renderedPath {
+		t.Fatalf("symlink target = %q, want %q", linkTarget, renderedPath)
+	}
+}
+
+func TestApplyOneModeOnlyDoesNotChmodRepoSource(t *testing.T) {
+	repoDir := t.TempDir()
+	srcPath := filepath.Join(repoDir, "plain")
+	if err := ioutil.WriteFile(srcPath, []byte("plain contents"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	dfo := &dfoState{config: dfoConfig{
+		RepoDir: repoDir,
+		WorkDir: t.TempDir(),
+		HomeDir: t.TempDir(),
+	}}
+
+	file := dotfileDef{src: "plain", dst: "out", mode: 0600}
+	res := dfo.applyOne(file, nil, newDirLocks())
+	if res.err != nil {
+		t.Fatalf("applyOne: %v", res.err)
+	}
+
+	fi, err := os.Stat(srcPath)
+	if err != nil {
+		t.Fatalf("Stat(repo source): %v", err)
+	}
+	if fi.Mode().Perm() == 0600 {
+		t.Fatalf("mode was applied to the git-tracked repo source %q, want it untouched", srcPath)
+	}
+
+	ownedPath := filepath.Join(dfo.renderedDir(), "out")
+	fi, err = os.Stat(ownedPath)
+	if err != nil {
+		t.Fatalf("Stat(owned copy): %v", err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("owned copy mode = %v, want 0600", fi.Mode().Perm())
+	}
+}
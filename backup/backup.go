@@ -0,0 +1,56 @@
+// Package backup implements dfo's backup subsystem: recording every file a run replaces with a
+// symlink so the "backup before symlink" promise is actually reversible (listable, restorable,
+// prunable) instead of being a pile of untracked hardlinked copies.
+package backup
+
+import "time"
+
+// Entry describes a single file backed up during a run.
+type Entry struct {
+	Path       string // Path relative to the user's home directory
+	Mode       uint32 // os.FileMode of the original file
+	UID        int
+	GID        int
+	SHA256     string // Checksum of the original file's contents, empty for directories
+	LinkTarget string // Symlink target that replaced Path
+	IsDir      bool
+}
+
+// Manifest records every file backed up during a single run.
+type Manifest struct {
+	ID        string // Unique, filesystem-safe identifier for this run (also its timestamp)
+	Timestamp time.Time
+	Entries   []Entry
+}
+
+// RetentionPolicy controls which backup runs Prune keeps. A zero policy keeps everything.
+type RetentionPolicy struct {
+	KeepLast   int           // Keep at least this many most recent runs, 0 means no minimum
+	KeepWithin time.Duration // Keep any run newer than this, 0 means no time-based retention
+}
+
+// Store records and retrieves backup runs.
+type Store interface {
+	// Begin starts a new backup run, returning a Run to add entries to.
+	Begin() (Run, error)
+	// List returns the manifests of every backup run, most recent first.
+	List() ([]Manifest, error)
+	// Restore restores the files recorded in the run with the given id, removing the symlinks
+	// that replaced them.
+	Restore(id string) error
+	// RestorePath restores path from the most recent run that recorded it, removing the symlink
+	// that replaced it. It's a no-op if no run recorded path.
+	RestorePath(path string) error
+	// Prune deletes backup runs that fall outside of policy.
+	Prune(policy RetentionPolicy) error
+}
+
+// Run is a single backup run in progress: files are added to it as they're backed up, then
+// Close finalizes it by writing its manifest.
+type Run interface {
+	// BackupFile backs up the file at srcPath (its path relative to the user's home directory
+	// is path) before it gets replaced by a symlink pointing at linkTarget.
+	BackupFile(path, srcPath, linkTarget string) error
+	// Close finalizes the run, writing its manifest.
+	Close() error
+}
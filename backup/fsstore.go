@@ -0,0 +1,353 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Format selects how a run's file contents are stored on disk.
+type Format string
+
+const (
+	// FormatCopy hardlinks (or, for directories, recursively copies) each backed-up file into
+	// the run's directory. This is the original dfo behavior.
+	FormatCopy Format = "copy"
+	// FormatTarGz stores a run's files in a single backup.tar.gz, useful for repos where
+	// cross-device hardlinks fail.
+	FormatTarGz Format = "tar.gz"
+)
+
+// FSStore is a filesystem-backed Store: runs live as subdirectories of dir, each named after
+// its own ID and holding a manifest.yaml plus either copied files or a backup.tar.gz.
+type FSStore struct {
+	dir     string // Root backups directory, typically WorkDir/backups
+	homeDir string // Home directory that Entry.Path is relative to
+	format  Format
+}
+
+// NewFSStore returns a Store that keeps its runs under dir, restoring files relative to
+// homeDir. An empty format defaults to FormatCopy.
+func NewFSStore(dir, homeDir string, format Format) *FSStore {
+	if format == "" {
+		format = FormatCopy
+	}
+	return &FSStore{dir: dir, homeDir: homeDir, format: format}
+}
+
+func (s *FSStore) Begin() (Run, error) {
+	now := time.Now()
+	id := runID(now)
+	dir := filepath.Join(s.dir, id)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	run := &fsRun{
+		store:    s,
+		dir:      dir,
+		manifest: Manifest{ID: id, Timestamp: now},
+	}
+
+	if s.format == FormatTarGz {
+		tw, closeArchive, err := newTarWriter(filepath.Join(dir, "backup.tar.gz"))
+		if err != nil {
+			return nil, err
+		}
+		run.tw = tw
+		run.closeArchive = closeArchive
+	}
+
+	return run, nil
+}
+
+func (s *FSStore) List() ([]Manifest, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var manifests []Manifest
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		m, err := readManifest(filepath.Join(s.dir, e.Name(), manifestFile))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].Timestamp.After(manifests[j].Timestamp) })
+	return manifests, nil
+}
+
+func (s *FSStore) Restore(id string) error {
+	dir := filepath.Join(s.dir, id)
+	m, err := readManifest(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return err
+	}
+
+	for _, e := range m.Entries {
+		if err := s.restoreEntry(dir, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RestorePath restores path from the most recent run whose manifest recorded it, searching
+// newest-first. It's a no-op if no run ever backed up path.
+func (s *FSStore) RestorePath(path string) error {
+	manifests, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range manifests {
+		for _, e := range m.Entries {
+			if e.Path != path {
+				continue
+			}
+			return s.restoreEntry(filepath.Join(s.dir, m.ID), e)
+		}
+	}
+
+	return nil
+}
+
+// restoreEntry restores a single manifest entry backed up under dir, removing the symlink that
+// replaced it.
+func (s *FSStore) restoreEntry(dir string, e Entry) error {
+	targetPath := filepath.Join(s.homeDir, e.Path)
+
+	if fi, err := os.Lstat(targetPath); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(targetPath); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+
+	if e.IsDir {
+		if s.format == FormatTarGz {
+			tr, err := openTarReader(filepath.Join(dir, "backup.tar.gz"))
+			if err != nil {
+				return err
+			}
+			defer tr.Close()
+			return tr.extractDir(e.Path, targetPath)
+		}
+		return copyDir(filepath.Join(dir, "files", e.Path), targetPath)
+	}
+
+	if s.format == FormatTarGz {
+		tr, err := openTarReader(filepath.Join(dir, "backup.tar.gz"))
+		if err != nil {
+			return err
+		}
+		defer tr.Close()
+		return tr.extract(e.Path, targetPath, os.FileMode(e.Mode))
+	}
+
+	srcPath := filepath.Join(dir, "files", e.Path)
+	return copyFile(srcPath, targetPath, os.FileMode(e.Mode))
+}
+
+func (s *FSStore) Prune(policy RetentionPolicy) error {
+	if policy.KeepLast <= 0 && policy.KeepWithin <= 0 {
+		return nil
+	}
+
+	manifests, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i, m := range manifests {
+		if policy.KeepLast > 0 && i < policy.KeepLast {
+			continue
+		}
+		if policy.KeepWithin > 0 && now.Sub(m.Timestamp) < policy.KeepWithin {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.dir, m.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runID turns t into a filesystem-safe, sortable run identifier.
+func runID(t time.Time) string {
+	return strings.ReplaceAll(t.Format(time.RFC3339Nano), ":", "-")
+}
+
+type fsRun struct {
+	store        *FSStore
+	dir          string
+	mu           sync.Mutex // guards manifest and tw, which BackupFile may be called on concurrently
+	manifest     Manifest
+	tw           *tarWriter
+	closeArchive func() error
+}
+
+// BackupFile is safe to call concurrently: callers applying dotfiles in parallel share a
+// single Run per apply, so writes to the manifest and (for FormatTarGz) the archive are
+// serialized internally.
+func (r *fsRun) BackupFile(path, srcPath, linkTarget string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fi, err := os.Lstat(srcPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	entry := Entry{
+		Path:       path,
+		Mode:       uint32(fi.Mode()),
+		LinkTarget: linkTarget,
+		IsDir:      fi.IsDir(),
+	}
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		entry.UID = int(stat.Uid)
+		entry.GID = int(stat.Gid)
+	}
+
+	if !fi.IsDir() {
+		sum, err := sha256File(srcPath)
+		if err != nil {
+			return err
+		}
+		entry.SHA256 = sum
+	}
+
+	if r.tw != nil {
+		if err := r.tw.add(path, srcPath, fi); err != nil {
+			return err
+		}
+	} else if err := r.copyToRun(path, srcPath, fi); err != nil {
+		return err
+	}
+
+	r.manifest.Entries = append(r.manifest.Entries, entry)
+	return nil
+}
+
+// copyToRun hardlinks (or, for directories, recursively copies) srcPath into this run's
+// "files" directory, preserving dfo's original backup strategy.
+func (r *fsRun) copyToRun(path, srcPath string, fi os.FileInfo) error {
+	destPath := filepath.Join(r.dir, "files", path)
+
+	if fi.IsDir() {
+		return copyDir(srcPath, destPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return err
+	}
+	return os.Link(srcPath, destPath)
+}
+
+func (r *fsRun) Close() error {
+	if r.closeArchive != nil {
+		if err := r.closeArchive(); err != nil {
+			return err
+		}
+	}
+	return writeManifest(filepath.Join(r.dir, manifestFile), r.manifest)
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func copyFile(srcPath, destPath string, mode os.FileMode) error {
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	destFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	_, err = io.Copy(destFile, srcFile)
+	return err
+}
+
+func copyDir(srcPath, destPath string) error {
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(destPath, srcInfo.Mode()); err != nil {
+		return err
+	}
+
+	dir, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	objects, err := dir.Readdir(-1)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		srcFile := filepath.Join(srcPath, obj.Name())
+		destFile := filepath.Join(destPath, obj.Name())
+
+		if obj.IsDir() {
+			if err := copyDir(srcFile, destFile); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.Link(srcFile, destFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
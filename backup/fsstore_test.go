@@ -0,0 +1,199 @@
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// backUp runs a full Begin/BackupFile/Close cycle for a single file at path (relative to home),
+// returning the run's manifest ID.
+func backUp(t *testing.T, s *FSStore, home, path, contents, linkTarget string) string {
+	t.Helper()
+
+	srcPath := filepath.Join(home, path)
+	if err := os.MkdirAll(filepath.Dir(srcPath), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	// Remove rather than overwrite: with FormatCopy an earlier run's backup is a hardlink to this
+	// same file, and writing to it in place would change that backup's contents too.
+	if err := os.Remove(srcPath); err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := ioutil.WriteFile(srcPath, []byte(contents), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	run, err := s.Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if err := run.BackupFile(path, srcPath, linkTarget); err != nil {
+		t.Fatalf("BackupFile: %v", err)
+	}
+	if err := run.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	manifests, err := s.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	return manifests[0].ID
+}
+
+// backUpDir runs a full Begin/BackupFile/Close cycle for a directory at path (relative to
+// home) containing a couple of files, one of them in a nested subdirectory, returning the run's
+// manifest ID.
+func backUpDir(t *testing.T, s *FSStore, home, path string) string {
+	t.Helper()
+
+	dirPath := filepath.Join(home, path)
+	if err := os.MkdirAll(filepath.Join(dirPath, "sub"), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dirPath, "a"), []byte("file a"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dirPath, "sub", "b"), []byte("file b"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	run, err := s.Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if err := run.BackupFile(path, dirPath, "/dotfiles/"+path); err != nil {
+		t.Fatalf("BackupFile: %v", err)
+	}
+	if err := run.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	manifests, err := s.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	return manifests[0].ID
+}
+
+func TestFSStoreRestoreDirectory(t *testing.T) {
+	for _, format := range []Format{FormatCopy, FormatTarGz} {
+		t.Run(string(format), func(t *testing.T) {
+			home := t.TempDir()
+			s := NewFSStore(t.TempDir(), home, format)
+
+			id := backUpDir(t, s, home, "somedir")
+
+			dirPath := filepath.Join(home, "somedir")
+			if err := os.RemoveAll(dirPath); err != nil {
+				t.Fatalf("RemoveAll: %v", err)
+			}
+			if err := os.Symlink("/dotfiles/somedir", dirPath); err != nil {
+				t.Fatalf("Symlink: %v", err)
+			}
+
+			if err := s.Restore(id); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+
+			fi, err := os.Lstat(dirPath)
+			if err != nil {
+				t.Fatalf("Lstat: %v", err)
+			}
+			if fi.Mode()&os.ModeSymlink != 0 {
+				t.Fatalf("Restore left %q as a symlink, want a regular directory", dirPath)
+			}
+			if !fi.IsDir() {
+				t.Fatalf("Restore replaced %q with a non-directory", dirPath)
+			}
+
+			for name, want := range map[string]string{"a": "file a", filepath.Join("sub", "b"): "file b"} {
+				got, err := ioutil.ReadFile(filepath.Join(dirPath, name))
+				if err != nil {
+					t.Fatalf("ReadFile(%q): %v", name, err)
+				}
+				if string(got) != want {
+					t.Fatalf("contents of %q = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFSStoreRestore(t *testing.T) {
+	for _, format := range []Format{FormatCopy, FormatTarGz} {
+		t.Run(string(format), func(t *testing.T) {
+			home := t.TempDir()
+			s := NewFSStore(t.TempDir(), home, format)
+
+			id := backUp(t, s, home, "foo", "original contents", "/dotfiles/foo")
+
+			targetPath := filepath.Join(home, "foo")
+			if err := os.Remove(targetPath); err != nil {
+				t.Fatalf("Remove: %v", err)
+			}
+			if err := os.Symlink("/dotfiles/foo", targetPath); err != nil {
+				t.Fatalf("Symlink: %v", err)
+			}
+
+			if err := s.Restore(id); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+
+			fi, err := os.Lstat(targetPath)
+			if err != nil {
+				t.Fatalf("Lstat: %v", err)
+			}
+			if fi.Mode()&os.ModeSymlink != 0 {
+				t.Fatalf("Restore left %q as a symlink, want a regular file", targetPath)
+			}
+
+			got, err := ioutil.ReadFile(targetPath)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if string(got) != "original contents" {
+				t.Fatalf("restored contents = %q, want %q", got, "original contents")
+			}
+		})
+	}
+}
+
+func TestFSStoreRestorePathUsesMostRecentRun(t *testing.T) {
+	home := t.TempDir()
+	s := NewFSStore(t.TempDir(), home, FormatCopy)
+
+	backUp(t, s, home, "foo", "first backup", "/dotfiles/foo")
+	backUp(t, s, home, "foo", "second backup", "/dotfiles/foo")
+
+	targetPath := filepath.Join(home, "foo")
+	if err := os.Remove(targetPath); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := os.Symlink("/dotfiles/foo", targetPath); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	if err := s.RestorePath("foo"); err != nil {
+		t.Fatalf("RestorePath: %v", err)
+	}
+
+	got, err := ioutil.ReadFile(targetPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "second backup" {
+		t.Fatalf("restored contents = %q, want %q (most recent run)", got, "second backup")
+	}
+}
+
+func TestFSStoreRestorePathNoBackupIsNoop(t *testing.T) {
+	home := t.TempDir()
+	s := NewFSStore(t.TempDir(), home, FormatCopy)
+
+	if err := s.RestorePath("never-backed-up"); err != nil {
+		t.Fatalf("RestorePath: %v", err)
+	}
+}
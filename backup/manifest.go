@@ -0,0 +1,27 @@
+package backup
+
+import (
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+const manifestFile = "manifest.yaml"
+
+func writeManifest(path string, m Manifest) error {
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+func readManifest(path string) (Manifest, error) {
+	var m Manifest
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	err = yaml.Unmarshal(b, &m)
+	return m, err
+}
@@ -0,0 +1,214 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tarWriter streams backed-up files into a gzip-compressed tar archive.
+type tarWriter struct {
+	tw *tar.Writer
+}
+
+// newTarWriter creates path and returns a tarWriter writing to it, plus a close func that
+// flushes and closes the tar/gzip/file layers in order.
+func newTarWriter(path string) (*tarWriter, func() error, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+
+	closeFn := func() error {
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		if err := gzw.Close(); err != nil {
+			return err
+		}
+		return f.Close()
+	}
+
+	return &tarWriter{tw: tw}, closeFn, nil
+}
+
+func (w *tarWriter) add(path, srcPath string, fi os.FileInfo) error {
+	hdr, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = path
+
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	if fi.IsDir() {
+		return w.addDir(path, srcPath)
+	}
+
+	f, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w.tw, f)
+	return err
+}
+
+// addDir recursively adds every file and subdirectory under srcPath, with each entry's name
+// prefixed by path so a restore can later find them all by the dfo-relative path the directory
+// itself was backed up under.
+func (w *tarWriter) addDir(path, srcPath string) error {
+	entries, err := os.ReadDir(srcPath)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		childSrc := filepath.Join(srcPath, e.Name())
+		childPath := filepath.Join(path, e.Name())
+
+		fi, err := e.Info()
+		if err != nil {
+			return err
+		}
+
+		if err := w.add(childPath, childSrc, fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tarReader opens a run's backup.tar.gz for random-access extraction of individual entries.
+type tarReader struct {
+	f *os.File
+}
+
+func openTarReader(path string) (*tarReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &tarReader{f: f}, nil
+}
+
+// extract writes the contents of the entry named name out to destPath. The archive is
+// re-scanned from the start on every call: dotfiles backups are small enough that this is
+// simpler than keeping an in-memory index around.
+func (r *tarReader) extract(name, destPath string, mode os.FileMode) error {
+	if _, err := r.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	gzr, err := gzip.NewReader(r.f)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return os.ErrNotExist
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Name != name {
+			continue
+		}
+
+		out, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		_, err = io.Copy(out, tr)
+		return err
+	}
+}
+
+// extractDir writes every entry named tarDir, or nested under tarDir/, out under destDir,
+// preserving the archive's directory structure and file modes. The archive is re-scanned from
+// the start, same as extract.
+func (r *tarReader) extractDir(tarDir, destDir string) error {
+	if _, err := r.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	gzr, err := gzip.NewReader(r.f)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		rel, ok := relTo(hdr.Name, tarDir)
+		if !ok {
+			continue
+		}
+
+		destPath := destDir
+		if rel != "" {
+			destPath = filepath.Join(destDir, rel)
+		}
+
+		if hdr.FileInfo().IsDir() {
+			if err := os.MkdirAll(destPath, os.FileMode(hdr.Mode)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(hdr.Mode))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+}
+
+// relTo returns name's path relative to dir ("" if name == dir) and true, or ("", false) if
+// name is neither dir nor nested under it.
+func relTo(name, dir string) (string, bool) {
+	if name == dir {
+		return "", true
+	}
+	if rel := strings.TrimPrefix(name, dir+"/"); rel != name {
+		return rel, true
+	}
+	return "", false
+}
+
+func (r *tarReader) Close() error {
+	return r.f.Close()
+}
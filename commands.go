@@ -0,0 +1,77 @@
+// dfo backup/restore subcommands: `dfo backup list`, `dfo backup prune` and
+// `dfo restore <id>`. These operate on the backup store directly, without touching dfo.yaml or
+// cloning/updating the dotfiles repo.
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/FranGM/dfo/backup"
+	"github.com/FranGM/simplelog"
+)
+
+// runBackupSubcommand handles `dfo backup <list|prune>` and `dfo restore <id>`. It reports
+// whether args matched one of these subcommands, so main() can skip the normal apply run.
+func runBackupSubcommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "backup":
+		if len(args) < 2 {
+			simplelog.Fatal.Printf("Usage: dfo backup <list|prune>")
+		}
+		handleBackupCommand(args[1])
+		return true
+	case "restore":
+		if len(args) < 2 {
+			simplelog.Fatal.Printf("Usage: dfo restore <id>")
+		}
+		handleRestoreCommand(args[1])
+		return true
+	default:
+		return false
+	}
+}
+
+func loadedConfig() dfoConfig {
+	var c dfoConfig
+	c.setDefaults()
+	if err := c.loadConfig(); err != nil {
+		simplelog.Fatal.Printf("Error loading config file: %q", err)
+	}
+	return c
+}
+
+func handleBackupCommand(subcommand string) {
+	config := loadedConfig()
+	store := newBackupStore(config)
+
+	switch subcommand {
+	case "list":
+		manifests, err := store.List()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range manifests {
+			fmt.Printf("%s\t%d files\n", m.ID, len(m.Entries))
+		}
+	case "prune":
+		policy := backup.RetentionPolicy{KeepLast: config.KeepLast, KeepWithin: config.KeepWithin}
+		if err := store.Prune(policy); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		simplelog.Fatal.Printf("Unknown backup subcommand %q", subcommand)
+	}
+}
+
+func handleRestoreCommand(id string) {
+	config := loadedConfig()
+	if err := newBackupStore(config).Restore(id); err != nil {
+		log.Fatal(err)
+	}
+}
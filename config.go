@@ -5,19 +5,50 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 type dfoConfig struct {
-	RepoDir   string // Directory where to store dotfiles repo
-	HomeDir   string // User's home directory. Relative target paths will be relative to this
-	WorkDir   string // dfo's work directory (~/.dfo)
-	GitRepo   string // Git repository that stores user's dotfiles
-	Noop      bool   // Do not replace any files
-	Verbose   bool   // Run dfo in verbose mode
-	Backup    bool   // Make backups of files before replacing them
-	UpdateGit bool   // Update dotfiles repo from origin before applying any changes
+	RepoDir   string   // Directory where to store dotfiles repo
+	HomeDir   string   // User's home directory. Relative target paths will be relative to this
+	WorkDir   string   // dfo's work directory (~/.dfo)
+	GitRepo   string   // Git repository that stores user's dotfiles
+	Noop      bool     // Do not replace any files
+	Verbose   bool     // Run dfo in verbose mode
+	Backup    bool     // Make backups of files before replacing them
+	UpdateGit bool     // Update dotfiles repo from origin before applying any changes
+	Tags      []string // User-defined tags, made available to `when` conditions and templates
+
+	GitBackend string // Git backend to use: "exec" or "gogit" (default "gogit")
+	GitRemote  string // Remote name to use, defaults to "origin"
+
+	// GitBranch is the remote branch to track, defaulting to the repo's default branch. Only
+	// honored by the gogit backend, and only at clone time: the exec backend ignores it
+	// entirely, and gogit's Update follows whatever branch is already checked out rather than
+	// re-pinning to GitBranch.
+	GitBranch string
+
+	GitDepth     int            // Shallow clone depth, gogit backend only. 0 means a full clone
+	IdentityFile string         // SSH private key to use for auth, gogit backend only
+	Credentials  gitCredentials // HTTP basic/token auth for the git remote, gogit backend only
+
+	BackupFormat string        // Backup storage strategy: "copy" (default) or "tar.gz"
+	KeepLast     int           // Retention: always keep at least this many backup runs
+	KeepWithin   time.Duration // Retention: always keep backup runs newer than this
+
+	ContinueOnHookError bool // Keep going when a before/after hook or lifecycle script fails
+
+	Parallelism int // Number of dotfiles to apply concurrently, defaults to runtime.NumCPU()
+
+	Sync bool // Treat dfo.yaml as authoritative: remove symlinks for entries no longer listed
+}
+
+type gitCredentials struct {
+	Username string
+	Password string
 }
 
 func (c *dfoConfig) loadConfig() error {
@@ -41,6 +72,9 @@ func (c *dfoConfig) setDefaults() {
 	c.WorkDir = filepath.Join(c.HomeDir, ".dfo")
 	c.RepoDir = filepath.Join(c.WorkDir, "dotfiles")
 	c.UpdateGit = true
+	c.GitBackend = "gogit"
+	c.BackupFormat = "copy"
+	c.Parallelism = runtime.NumCPU()
 }
 
 func (c *dfoConfig) initFromParams() {
@@ -51,6 +85,9 @@ func (c *dfoConfig) initFromParams() {
 	flag.BoolVar(&c.Verbose, "verbose", c.Verbose, "Verbose output")
 	flag.BoolVar(&c.Backup, "backup", c.Backup, "Perform backups of files that are updated")
 	flag.BoolVar(&c.UpdateGit, "updategit", c.UpdateGit, "Do a 'git pull' and update submodules of the git repo")
+	flag.BoolVar(&c.ContinueOnHookError, "continue-on-hook-error", c.ContinueOnHookError, "Keep going when a before/after hook or lifecycle script fails")
+	flag.IntVar(&c.Parallelism, "parallelism", c.Parallelism, "Number of dotfiles to apply concurrently")
+	flag.BoolVar(&c.Sync, "sync", c.Sync, "Remove symlinks for entries no longer listed in dfo.yaml, restoring their backup if one exists")
 
 	flag.Parse()
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/Knetic/govaluate"
+)
+
+// buildFacts collects the set of facts available to `when` conditions and templates:
+// OS/arch, hostname, environment variables and any tags defined in config.yaml.
+func buildFacts(c dfoConfig) (map[string]interface{}, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	facts := map[string]interface{}{
+		"os":   runtime.GOOS,
+		"arch": runtime.GOARCH,
+		"host": hostname,
+		"env":  envMap(),
+		"tags": c.Tags,
+	}
+	return facts, nil
+}
+
+// envMap turns the process environment into a map, so templates can refer to env vars as e.g.
+// {{.env.USER}}. A `when` expression cannot read a single env var this way: govaluate treats
+// env.USER as a struct field access, and env is a map.
+func envMap() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		for i := 0; i < len(kv); i++ {
+			if kv[i] == '=' {
+				env[kv[:i]] = kv[i+1:]
+				break
+			}
+		}
+	}
+	return env
+}
+
+// evalWhen evaluates a `when` condition (e.g. `os == 'darwin' && host =~ 'work-.*'`) against
+// facts. An empty condition always matches, so entries without a `when` behave as before.
+func evalWhen(when string, facts map[string]interface{}) (bool, error) {
+	if when == "" {
+		return true, nil
+	}
+
+	expr, err := govaluate.NewEvaluableExpression(when)
+	if err != nil {
+		return false, fmt.Errorf("invalid when condition %q: %s", when, err)
+	}
+
+	result, err := expr.Evaluate(facts)
+	if err != nil {
+		return false, fmt.Errorf("evaluating when condition %q: %s", when, err)
+	}
+
+	matched, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("when condition %q did not evaluate to a boolean", when)
+	}
+	return matched, nil
+}
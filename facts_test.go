@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnvMap(t *testing.T) {
+	if err := os.Setenv("DFO_TEST_ENV_VAR", "somevalue"); err != nil {
+		t.Fatalf("Setenv: %v", err)
+	}
+	defer os.Unsetenv("DFO_TEST_ENV_VAR")
+
+	env := envMap()
+	if got := env["DFO_TEST_ENV_VAR"]; got != "somevalue" {
+		t.Fatalf("envMap()[%q] = %q, want %q", "DFO_TEST_ENV_VAR", got, "somevalue")
+	}
+}
+
+func TestEvalWhen(t *testing.T) {
+	facts := map[string]interface{}{
+		"os":   "darwin",
+		"arch": "amd64",
+		"host": "work-laptop",
+	}
+
+	tests := []struct {
+		name    string
+		when    string
+		want    bool
+		wantErr bool
+	}{
+		{name: "empty always matches", when: "", want: true},
+		{name: "simple equality match", when: "os == 'darwin'", want: true},
+		{name: "simple equality no match", when: "os == 'linux'", want: false},
+		{name: "combined conditions match", when: "os == 'darwin' && arch == 'amd64'", want: true},
+		{name: "regex operator match", when: "host =~ 'work-.*'", want: true},
+		{name: "regex operator no match", when: "host =~ 'home-.*'", want: false},
+		{name: "invalid syntax errors", when: "os ==", wantErr: true},
+		{name: "non-boolean result errors", when: "1 + 1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evalWhen(tt.when, facts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("evalWhen(%q) = nil error, want error", tt.when)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("evalWhen(%q): %v", tt.when, err)
+			}
+			if got != tt.want {
+				t.Fatalf("evalWhen(%q) = %v, want %v", tt.when, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildFacts(t *testing.T) {
+	config := dfoConfig{Tags: []string{"work", "laptop"}}
+
+	facts, err := buildFacts(config)
+	if err != nil {
+		t.Fatalf("buildFacts: %v", err)
+	}
+
+	for _, key := range []string{"os", "arch", "host", "env", "tags"} {
+		if _, ok := facts[key]; !ok {
+			t.Errorf("buildFacts() missing key %q", key)
+		}
+	}
+
+	tags, ok := facts["tags"].([]string)
+	if !ok || len(tags) != 2 || tags[0] != "work" || tags[1] != "laptop" {
+		t.Errorf("buildFacts()[\"tags\"] = %v, want %v", facts["tags"], config.Tags)
+	}
+}
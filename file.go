@@ -1,42 +1,17 @@
 package main
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
-	"time"
 
+	"github.com/FranGM/dfo/backup"
 	"github.com/FranGM/simplelog"
 )
 
-// getBackupDirName generates a directory name to store backups
-//   based on the current time.
-func (dfo *dfoState) getBackupDirName() string {
-	if dfo.backupDir != "" {
-		return dfo.backupDir
-	}
-
-	t := time.Now()
-	b, _ := t.MarshalText()
-
-	curDate := string(b)
-	dirName := fmt.Sprintf("backups/dfo_backup_%v", curDate)
-	dfo.backupDir = filepath.Join(dfo.config.WorkDir, dirName)
-	return dfo.backupDir
-}
-
-// createBackupDir creates a backup directory for the dotfiles.
-// If directory already exists no errors will be reported
-func (dfo *dfoState) createBackupDir(backupDir string) error {
-	simplelog.Debug.Printf("Ensuring backup directory (%q) exists", backupDir)
-	if dfo.config.Noop {
-		return nil
-	}
-	err := os.Mkdir(backupDir, 0755)
-	if os.IsExist(err) {
-		return nil
-	}
-	return err
+// newBackupStore builds the backup.Store used for this run, based on c.BackupFormat.
+func newBackupStore(c dfoConfig) *backup.FSStore {
+	format := backup.Format(c.BackupFormat)
+	return backup.NewFSStore(filepath.Join(c.WorkDir, "backups"), c.HomeDir, format)
 }
 
 // fileNeedsUpdating returns true if the file should be updated. This means either:
@@ -60,7 +35,12 @@ func fileNeedsUpdating(path string, newSrc string, config dfoConfig) (bool, erro
 		if err != nil {
 			return false, err
 		}
-		absSrc := filepath.Join(config.RepoDir, newSrc)
+		// Paths in dfo.yaml (and rendered template output) can be either absolute or relative
+		// to our dotfiles repo
+		absSrc := newSrc
+		if !filepath.IsAbs(newSrc) {
+			absSrc = filepath.Join(config.RepoDir, newSrc)
+		}
 		// TODO: There's probably a better way of comparing them
 		if absSrc == linkTarget {
 			return false, nil
@@ -69,57 +49,21 @@ func fileNeedsUpdating(path string, newSrc string, config dfoConfig) (bool, erro
 	return true, nil
 }
 
-// backupFile takes a backup of the given file and stores it in the backup directory
-// path of the file to be backed up is relative to the user's home dir
-func (dfo *dfoState) backupFile(path string) error {
-	simplelog.Info.Printf("Backing up %q", path)
-
-	srcPath := filepath.Join(dfo.config.HomeDir, path)
-	targetBackupPath := filepath.Join(dfo.getBackupDirName(), path)
-	targetDir := filepath.Dir(targetBackupPath)
-
-	// If there's no source file there's nothing to backup
-	fi, err := os.Stat(srcPath)
-	if os.IsNotExist(err) {
-		return nil
-	}
-
-	// Create backup directory if it doesn't exist already
-	err = dfo.createBackupDir(dfo.getBackupDirName())
-	if err != nil {
-		return err
-	}
-
-	simplelog.Debug.Printf("Ensuring %q exists before backing up file", targetDir)
-	// Create any subdirectories we might need
-	if !dfo.config.Noop {
-		err = os.MkdirAll(targetDir, 0755)
-		if err != nil {
-			return err
-		}
-	}
-
-	simplelog.Debug.Printf("Backing up %q into %q", srcPath, targetBackupPath)
-
-	if dfo.config.Noop {
-		return nil
-	}
-
-	if fi.IsDir() {
-		return copyDir(srcPath, targetBackupPath)
-	}
-
-	return os.Link(srcPath, targetBackupPath)
-}
-
 // replaceFile replaces a existing file with a symlink to src
 // target file should have been backed up previously
 func (dfo *dfoState) replaceFile(target string, src string) error {
+	dfo.markManaged(target)
+
 	targetPath := filepath.Join(dfo.config.HomeDir, target)
 
-	if dfo.config.Backup {
-		err := dfo.backupFile(target)
-		if err != nil {
+	// Paths in dfo.yaml can be either absolute or relative to our dotfiles repo
+	absSrc := src
+	if !filepath.IsAbs(src) {
+		absSrc = filepath.Join(dfo.config.RepoDir, src)
+	}
+
+	if dfo.config.Backup && dfo.backupRun != nil {
+		if err := dfo.backupRun.BackupFile(target, targetPath, absSrc); err != nil {
 			return err
 		}
 	}
@@ -143,12 +87,6 @@ func (dfo *dfoState) replaceFile(target string, src string) error {
 		}
 	}
 
-	// Paths in dfo.yaml can be either absolute or relative to our dotfiles repo
-	absSrc := src
-	if !filepath.IsAbs(src) {
-		absSrc = filepath.Join(dfo.config.RepoDir, src)
-	}
-
 	simplelog.Info.Printf("%q -> %q", absSrc, targetPath)
 	if dfo.config.Noop {
 		return nil
@@ -156,38 +94,3 @@ func (dfo *dfoState) replaceFile(target string, src string) error {
 	err := os.Symlink(absSrc, targetPath)
 	return err
 }
-
-func copyDir(srcPath string, destPath string) error {
-
-	srcInfo, err := os.Stat(srcPath)
-	if err != nil {
-		return err
-	}
-
-	// create dest dir
-	err = os.MkdirAll(destPath, srcInfo.Mode())
-	if err != nil {
-		return err
-	}
-
-	dir, _ := os.Open(srcPath)
-	objects, err := dir.Readdir(-1)
-
-	for _, obj := range objects {
-		srcFile := filepath.Join(srcPath, obj.Name())
-		destFile := filepath.Join(destPath, obj.Name())
-
-		if obj.IsDir() {
-			err = copyDir(srcFile, destFile)
-			if err != nil {
-				return err
-			}
-		} else {
-			err = os.Link(srcFile, destFile)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
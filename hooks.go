@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/FranGM/simplelog"
+)
+
+// lifecycleScripts are repo-level scripts, run once after all symlinks have been applied,
+// matching the common dotfiles convention of a single install entrypoint.
+var lifecycleScripts = []string{"install.sh", "bootstrap.sh", "setup.sh"}
+
+// runHook runs a before/after hook for a dotfile: either an inline shell snippet or a path to
+// a script in the dotfiles repo (e.g. "./scripts/foo.sh"). It runs with the dotfiles repo as
+// its working directory and DFO_REPO_DIR/DFO_HOME_DIR/DFO_TARGET/DFO_SRC set, and honors Noop
+// by printing the command instead of running it.
+func (dfo *dfoState) runHook(hook, target, src string) error {
+	if hook == "" {
+		return nil
+	}
+
+	if dfo.config.Noop {
+		simplelog.Info.Printf("Would run hook for %q: %s", target, hook)
+		return nil
+	}
+
+	env := append(os.Environ(),
+		"DFO_REPO_DIR="+dfo.config.RepoDir,
+		"DFO_HOME_DIR="+dfo.config.HomeDir,
+		"DFO_TARGET="+target,
+		"DFO_SRC="+src,
+	)
+
+	simplelog.Debug.Printf("Running hook for %q: %s", target, hook)
+	return runStreamed(dfo.config.RepoDir, env, "sh", "-c", hook)
+}
+
+// runLifecycleScripts runs whichever of lifecycleScripts exist in the dotfiles repo root, once
+// all symlinks have been applied. Honors Noop the same way runHook does.
+func (dfo *dfoState) runLifecycleScripts() error {
+	env := append(os.Environ(),
+		"DFO_REPO_DIR="+dfo.config.RepoDir,
+		"DFO_HOME_DIR="+dfo.config.HomeDir,
+	)
+
+	for _, name := range lifecycleScripts {
+		scriptPath := filepath.Join(dfo.config.RepoDir, name)
+		if _, err := os.Stat(scriptPath); err != nil {
+			continue
+		}
+
+		if dfo.config.Noop {
+			simplelog.Info.Printf("Would run lifecycle script %q", scriptPath)
+			continue
+		}
+
+		simplelog.Info.Printf("Running lifecycle script %q", scriptPath)
+		if err := runStreamed(dfo.config.RepoDir, env, "sh", scriptPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runStreamed runs name with args in dir, streaming its stdout/stderr through simplelog as it
+// runs rather than buffering it until the command exits.
+func runStreamed(dir string, env []string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	go streamLines(stdout, simplelog.Info.Printf)
+	go streamLines(stderr, simplelog.Info.Printf)
+
+	return cmd.Wait()
+}
+
+func streamLines(r io.Reader, printf func(string, ...interface{})) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		printf("%s", scanner.Text())
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The hook run through runHook writes DFO_REPO_DIR/DFO_HOME_DIR/DFO_TARGET/DFO_SRC to a marker
+// file so we can assert on what the subprocess actually saw.
+func TestRunHookSetsEnv(t *testing.T) {
+	repoDir := t.TempDir()
+	markerPath := filepath.Join(repoDir, "marker")
+
+	dfo := &dfoState{config: dfoConfig{
+		RepoDir: repoDir,
+		HomeDir: t.TempDir(),
+	}}
+
+	hook := `printf '%s\n%s\n%s\n%s\n' "$DFO_REPO_DIR" "$DFO_HOME_DIR" "$DFO_TARGET" "$DFO_SRC" > ` + markerPath
+
+	if err := dfo.runHook(hook, "target", "src"); err != nil {
+		t.Fatalf("runHook: %v", err)
+	}
+
+	got, err := ioutil.ReadFile(markerPath)
+	if err != nil {
+		t.Fatalf("ReadFile(marker): %v", err)
+	}
+	want := dfo.config.RepoDir + "\n" + dfo.config.HomeDir + "\ntarget\nsrc\n"
+	if string(got) != want {
+		t.Fatalf("hook env = %q, want %q", got, want)
+	}
+}
+
+func TestRunHookEmptyIsNoop(t *testing.T) {
+	dfo := &dfoState{config: dfoConfig{RepoDir: t.TempDir(), HomeDir: t.TempDir()}}
+
+	if err := dfo.runHook("", "target", "src"); err != nil {
+		t.Fatalf("runHook: %v", err)
+	}
+}
+
+func TestRunHookNoopDoesNotRun(t *testing.T) {
+	repoDir := t.TempDir()
+	markerPath := filepath.Join(repoDir, "marker")
+
+	dfo := &dfoState{config: dfoConfig{
+		RepoDir: repoDir,
+		HomeDir: t.TempDir(),
+		Noop:    true,
+	}}
+
+	if err := dfo.runHook("touch "+markerPath, "target", "src"); err != nil {
+		t.Fatalf("runHook: %v", err)
+	}
+
+	if _, err := os.Stat(markerPath); !os.IsNotExist(err) {
+		t.Fatalf("Stat(marker) = %v, want IsNotExist (Noop must not run the hook)", err)
+	}
+}
+
+func TestRunLifecycleScriptsRunsWhicheverExist(t *testing.T) {
+	repoDir := t.TempDir()
+	markerPath := filepath.Join(repoDir, "marker")
+
+	if err := ioutil.WriteFile(filepath.Join(repoDir, "bootstrap.sh"), []byte("#!/bin/sh\ntouch "+markerPath+"\n"), 0755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	dfo := &dfoState{config: dfoConfig{RepoDir: repoDir, HomeDir: t.TempDir()}}
+
+	if err := dfo.runLifecycleScripts(); err != nil {
+		t.Fatalf("runLifecycleScripts: %v", err)
+	}
+
+	if _, err := os.Stat(markerPath); err != nil {
+		t.Fatalf("Stat(marker): %v (bootstrap.sh should have run)", err)
+	}
+}
+
+func TestRunLifecycleScriptsNoneExistIsNoop(t *testing.T) {
+	dfo := &dfoState{config: dfoConfig{RepoDir: t.TempDir(), HomeDir: t.TempDir()}}
+
+	if err := dfo.runLifecycleScripts(); err != nil {
+		t.Fatalf("runLifecycleScripts: %v", err)
+	}
+}
+
+func TestRunLifecycleScriptsNoopDoesNotRun(t *testing.T) {
+	repoDir := t.TempDir()
+	markerPath := filepath.Join(repoDir, "marker")
+
+	if err := ioutil.WriteFile(filepath.Join(repoDir, "setup.sh"), []byte("#!/bin/sh\ntouch "+markerPath+"\n"), 0755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	dfo := &dfoState{config: dfoConfig{RepoDir: repoDir, HomeDir: t.TempDir(), Noop: true}}
+
+	if err := dfo.runLifecycleScripts(); err != nil {
+		t.Fatalf("runLifecycleScripts: %v", err)
+	}
+
+	if _, err := os.Stat(markerPath); !os.IsNotExist(err) {
+		t.Fatalf("Stat(marker) = %v, want IsNotExist (Noop must not run setup.sh)", err)
+	}
+}
+
+func TestRunStreamedPropagatesExitError(t *testing.T) {
+	if err := runStreamed(t.TempDir(), os.Environ(), "sh", "-c", "exit 1"); err == nil {
+		t.Fatalf("runStreamed = nil error, want the command's exit error")
+	}
+}
@@ -0,0 +1,48 @@
+package gitbackend
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+// ExecBackend shells out to the git binary, preserving dfo's original behavior. It relies on
+// the user's own git config/ssh-agent/credential helpers for auth, so Config is ignored beyond
+// RemoteName.
+type ExecBackend struct {
+	remoteName string
+}
+
+// NewExecBackend returns a Backend that shells out to the git binary.
+func NewExecBackend(c Config) *ExecBackend {
+	remoteName := c.RemoteName
+	if remoteName == "" {
+		remoteName = "origin"
+	}
+	return &ExecBackend{remoteName: remoteName}
+}
+
+func (b *ExecBackend) Clone(repoURL, dir string) error {
+	return run("", "clone", "--origin", b.remoteName, repoURL, dir)
+}
+
+func (b *ExecBackend) Update(dir string) error {
+	return run(dir, "pull", b.remoteName)
+}
+
+func (b *ExecBackend) UpdateSubmodules(dir string) error {
+	return run(dir, "submodule", "update", "--init", "--recursive")
+}
+
+func run(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	var e bytes.Buffer
+	cmd.Stderr = &e
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %s", err.Error(), e.String())
+	}
+	return nil
+}
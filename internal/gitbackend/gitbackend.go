@@ -0,0 +1,35 @@
+// Package gitbackend provides pluggable implementations for cloning and updating dfo's
+// dotfiles git repository (and its submodules), so dfo isn't hard-wired to shelling out to a
+// git binary.
+package gitbackend
+
+// Backend clones and updates a git repository, including its submodules.
+type Backend interface {
+	// Clone clones repoURL into dir.
+	Clone(repoURL, dir string) error
+	// Update pulls the latest changes for the repo checked out in dir.
+	Update(dir string) error
+	// UpdateSubmodules initializes and updates (recursively) the submodules of the repo in dir.
+	UpdateSubmodules(dir string) error
+}
+
+// Config carries the options needed to construct a Backend. Not every field is meaningful to
+// every Backend: ExecBackend ignores everything but RemoteName, since the installed git binary
+// already reads ssh-agent/credential helpers/depth from the user's own git config.
+type Config struct {
+	RemoteName   string // Remote name to use, defaults to "origin"
+	Branch       string // Remote branch to track, defaults to the repo's default branch
+	Depth        int    // Shallow clone depth, 0 means a full clone
+	IdentityFile string // Path to an SSH private key to use for auth
+	SSHAuthSock  string // ssh-agent socket, defaults to $SSH_AUTH_SOCK. Only checked for being set: the agent connection itself always uses $SSH_AUTH_SOCK
+	Username     string // Username for HTTP basic/token auth, falls back to $DFO_GIT_USERNAME
+	Password     string // Password/token for HTTP basic/token auth, falls back to $DFO_GIT_PASSWORD
+}
+
+// New returns the Backend named by backend ("exec" or "gogit"), defaulting to GoGitBackend.
+func New(backend string, c Config) Backend {
+	if backend == "exec" {
+		return NewExecBackend(c)
+	}
+	return NewGoGitBackend(c)
+}
@@ -0,0 +1,143 @@
+package gitbackend
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// GoGitBackend implements Backend in-process via go-git, removing the dependency on a git
+// binary being installed (e.g. in minimal containers).
+type GoGitBackend struct {
+	config Config
+}
+
+// NewGoGitBackend returns a Backend that drives git in-process via go-git.
+func NewGoGitBackend(c Config) *GoGitBackend {
+	if c.RemoteName == "" {
+		c.RemoteName = "origin"
+	}
+	return &GoGitBackend{config: c}
+}
+
+func (b *GoGitBackend) Clone(repoURL, dir string) error {
+	auth, err := b.auth(repoURL)
+	if err != nil {
+		return err
+	}
+
+	opts := &git.CloneOptions{
+		URL:               repoURL,
+		RemoteName:        b.config.RemoteName,
+		Auth:              auth,
+		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+	}
+	if b.config.Depth > 0 {
+		opts.Depth = b.config.Depth
+	}
+	if b.config.Branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(b.config.Branch)
+	}
+
+	_, err = git.PlainClone(dir, false, opts)
+	return err
+}
+
+func (b *GoGitBackend) Update(dir string) error {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	remote, err := repo.Remote(b.config.RemoteName)
+	if err != nil {
+		return err
+	}
+
+	auth, err := b.auth(remote.Config().URLs[0])
+	if err != nil {
+		return err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+
+	err = wt.Pull(&git.PullOptions{RemoteName: b.config.RemoteName, ReferenceName: head.Name(), Auth: auth})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
+
+func (b *GoGitBackend) UpdateSubmodules(dir string) error {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	submodules, err := wt.Submodules()
+	if err != nil {
+		return err
+	}
+
+	return submodules.Update(&git.SubmoduleUpdateOptions{
+		Init:              true,
+		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+	})
+}
+
+// auth picks the transport auth method for repoURL: SSH (identity file, falling back to
+// ssh-agent) for ssh/git URLs, HTTP basic/token auth for http(s) URLs (from Config, falling back
+// to the DFO_GIT_USERNAME/DFO_GIT_PASSWORD env vars). Returns a nil AuthMethod (anonymous auth)
+// for schemes that don't need one, e.g. local file paths.
+func (b *GoGitBackend) auth(repoURL string) (transport.AuthMethod, error) {
+	ep, err := transport.NewEndpoint(repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ep.Protocol {
+	case "http", "https":
+		username, password := b.config.Username, b.config.Password
+		if username == "" && password == "" {
+			username = os.Getenv("DFO_GIT_USERNAME")
+			password = os.Getenv("DFO_GIT_PASSWORD")
+		}
+		if username == "" && password == "" {
+			return nil, nil
+		}
+		return &githttp.BasicAuth{Username: username, Password: password}, nil
+	case "ssh":
+		if b.config.IdentityFile != "" {
+			return gitssh.NewPublicKeysFromFile(ep.User, b.config.IdentityFile, "")
+		}
+
+		sock := b.config.SSHAuthSock
+		if sock == "" {
+			sock = os.Getenv("SSH_AUTH_SOCK")
+		}
+		if sock == "" {
+			return nil, fmt.Errorf("no identity_file configured and SSH_AUTH_SOCK is not set")
+		}
+		return gitssh.NewSSHAgentAuth(ep.User)
+	default:
+		return nil, nil
+	}
+}
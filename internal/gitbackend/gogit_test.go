@@ -0,0 +1,90 @@
+package gitbackend
+
+import (
+	"os"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+func TestGoGitBackendAuthHTTP(t *testing.T) {
+	os.Unsetenv("DFO_GIT_USERNAME")
+	os.Unsetenv("DFO_GIT_PASSWORD")
+
+	t.Run("config credentials", func(t *testing.T) {
+		b := NewGoGitBackend(Config{Username: "alice", Password: "secret"})
+
+		auth, err := b.auth("https://example.com/repo.git")
+		if err != nil {
+			t.Fatalf("auth: %v", err)
+		}
+		basic, ok := auth.(*githttp.BasicAuth)
+		if !ok {
+			t.Fatalf("auth = %T, want *githttp.BasicAuth", auth)
+		}
+		if basic.Username != "alice" || basic.Password != "secret" {
+			t.Fatalf("auth = %+v, want Username=alice Password=secret", basic)
+		}
+	})
+
+	t.Run("env var fallback", func(t *testing.T) {
+		os.Setenv("DFO_GIT_USERNAME", "bob")
+		os.Setenv("DFO_GIT_PASSWORD", "token")
+		defer os.Unsetenv("DFO_GIT_USERNAME")
+		defer os.Unsetenv("DFO_GIT_PASSWORD")
+
+		b := NewGoGitBackend(Config{})
+
+		auth, err := b.auth("https://example.com/repo.git")
+		if err != nil {
+			t.Fatalf("auth: %v", err)
+		}
+		basic, ok := auth.(*githttp.BasicAuth)
+		if !ok {
+			t.Fatalf("auth = %T, want *githttp.BasicAuth", auth)
+		}
+		if basic.Username != "bob" || basic.Password != "token" {
+			t.Fatalf("auth = %+v, want Username=bob Password=token", basic)
+		}
+	})
+
+	t.Run("no credentials anywhere is anonymous", func(t *testing.T) {
+		b := NewGoGitBackend(Config{})
+
+		auth, err := b.auth("https://example.com/repo.git")
+		if err != nil {
+			t.Fatalf("auth: %v", err)
+		}
+		if auth != nil {
+			t.Fatalf("auth = %v, want nil (anonymous)", auth)
+		}
+	})
+}
+
+func TestGoGitBackendAuthSSHNoIdentityNoAgentErrors(t *testing.T) {
+	old, hadOld := os.LookupEnv("SSH_AUTH_SOCK")
+	os.Unsetenv("SSH_AUTH_SOCK")
+	defer func() {
+		if hadOld {
+			os.Setenv("SSH_AUTH_SOCK", old)
+		}
+	}()
+
+	b := NewGoGitBackend(Config{})
+
+	if _, err := b.auth("git@example.com:user/repo.git"); err == nil {
+		t.Fatalf("auth() = nil error, want error (no identity_file, no SSH_AUTH_SOCK)")
+	}
+}
+
+func TestGoGitBackendAuthDefaultSchemeIsAnonymous(t *testing.T) {
+	b := NewGoGitBackend(Config{})
+
+	auth, err := b.auth("/local/path/to/repo")
+	if err != nil {
+		t.Fatalf("auth: %v", err)
+	}
+	if auth != nil {
+		t.Fatalf("auth = %v, want nil (anonymous)", auth)
+	}
+}
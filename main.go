@@ -3,27 +3,79 @@
 package main
 
 import (
-	"bytes"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sync"
 
+	"github.com/FranGM/dfo/backup"
+	"github.com/FranGM/dfo/internal/gitbackend"
+	"github.com/FranGM/dfo/state"
 	"github.com/FranGM/simplelog"
 	"gopkg.in/yaml.v2"
 )
 
 type dotfileDef struct {
-	src string
-	dst string
+	src      string
+	dst      string
+	template bool
+	mode     os.FileMode
+	before   string
+	after    string
+}
+
+// dotfileEntry is the YAML shape of a single dfo.yaml entry. It can be written either as a
+// plain "dst: src" string, or as a block supporting conditionals, templating, a mode and
+// before/after hooks:
+//
+//	dst: {src: file, when: "os == 'darwin' && host =~ 'work-.*'", template: true, mode: 0600}
+type dotfileEntry struct {
+	Src      string      `yaml:"src"`
+	When     string      `yaml:"when"`
+	Template bool        `yaml:"template"`
+	Mode     os.FileMode `yaml:"mode"`
+	Before   string      `yaml:"before"`
+	After    string      `yaml:"after"`
+}
+
+// UnmarshalYAML lets a dotfileEntry be written either as a plain string (just the src) or as
+// the full block form, so existing flat dfo.yaml files keep working unchanged.
+func (e *dotfileEntry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var src string
+	if err := unmarshal(&src); err == nil {
+		e.Src = src
+		return nil
+	}
+
+	type plain dotfileEntry
+	var p plain
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	*e = dotfileEntry(p)
+	return nil
 }
 
 type dfoState struct {
 	config    dfoConfig
-	backupDir string
+	backupRun backup.Run
 	dotfiles  []dotfileDef
+
+	managedMu sync.Mutex
+	managed   map[string]bool // Targets dfo has confirmed still apply this run, for --sync
+}
+
+// markManaged records target as one dfo currently manages, so a later --sync run can tell it
+// apart from a symlink dfo created for an entry that's since been removed from dfo.yaml. Safe
+// to call concurrently.
+func (dfo *dfoState) markManaged(target string) {
+	dfo.managedMu.Lock()
+	defer dfo.managedMu.Unlock()
+	if dfo.managed == nil {
+		dfo.managed = make(map[string]bool)
+	}
+	dfo.managed[target] = true
 }
 
 func (dfo *dfoState) initWorkDir() error {
@@ -68,51 +120,46 @@ func (dfo *dfoState) initWorkDir() error {
 	return nil
 }
 
+// newGitBackend builds the gitbackend.Backend selected by c.GitBackend ("exec" or "gogit").
+func newGitBackend(c dfoConfig) gitbackend.Backend {
+	return gitbackend.New(c.GitBackend, gitbackend.Config{
+		RemoteName:   c.GitRemote,
+		Branch:       c.GitBranch,
+		Depth:        c.GitDepth,
+		IdentityFile: c.IdentityFile,
+		Username:     c.Credentials.Username,
+		Password:     c.Credentials.Password,
+	})
+}
+
 // Clone our dotfiles git repo into our dfo working directory
 func initGitRepo(c dfoConfig) error {
-	cmd := exec.Command("git", "clone", c.GitRepo, c.RepoDir)
-
-	var e bytes.Buffer
-	cmd.Stderr = &e
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("%s: %s\n", err.Error(), e.String())
+	backend := newGitBackend(c)
+	if err := backend.Clone(c.GitRepo, c.RepoDir); err != nil {
+		return err
 	}
-
-	return updateGitSubmodules(c)
+	return backend.UpdateSubmodules(c.RepoDir)
 }
 
 // Does a git pull from the remote dotfiles git repo into our working copy
 func updateGitRepo(c dfoConfig) error {
-	var e bytes.Buffer
-
 	simplelog.Debug.Printf("Fetching updates from remote git repo...")
-	// Do a git pull
-	cmd := exec.Command("git", "pull")
-	cmd.Dir = c.RepoDir
-	cmd.Stderr = &e
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("%s: %s\n", err.Error(), e.String())
+	if err := newGitBackend(c).Update(c.RepoDir); err != nil {
+		return err
 	}
-
 	return updateGitSubmodules(c)
 }
 
 func updateGitSubmodules(c dfoConfig) error {
-	var e bytes.Buffer
-
 	simplelog.Debug.Printf("Updating git submodules...")
-	cmd := exec.Command("git", "submodule", "update", "--init", "--recursive")
-	cmd.Dir = c.RepoDir
-	cmd.Stderr = &e
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("%s: %s\n", err.Error(), e.String())
-	}
-
-	return nil
+	return newGitBackend(c).UpdateSubmodules(c.RepoDir)
 }
 
 func main() {
+	if runBackupSubcommand(os.Args[1:]) {
+		return
+	}
+
 	var dfo dfoState
 
 	dfo.config.setDefaults()
@@ -140,32 +187,73 @@ func main() {
 		log.Fatal(err)
 	}
 
-	m := make(map[string]string)
+	m := make(map[string]dotfileEntry)
 	err = yaml.Unmarshal(configBytes, &m)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	for dst, src := range m {
-		dfo.dotfiles = append(dfo.dotfiles, dotfileDef{dst: dst, src: src})
+	facts, err := buildFacts(dfo.config)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	simplelog.Debug.Printf("Backups will be stored in %q", dfo.getBackupDirName())
-
-	for _, file := range dfo.dotfiles {
-		needsUpdate, err := fileNeedsUpdating(file.dst, file.src, dfo.config)
+	for dst, entry := range m {
+		matched, err := evalWhen(entry.When, facts)
 		if err != nil {
 			log.Fatal(err)
 		}
-
-		if !needsUpdate {
-			simplelog.Debug.Printf("No changes needed for %v", file.dst)
+		if !matched {
+			simplelog.Debug.Printf("Skipping %q, when condition %q not met", dst, entry.When)
 			continue
 		}
 
-		err = dfo.replaceFile(file.dst, file.src)
+		dfo.dotfiles = append(dfo.dotfiles, dotfileDef{dst: dst, src: entry.Src, template: entry.Template, mode: entry.Mode, before: entry.Before, after: entry.After})
+	}
+
+	if dfo.config.Backup && !dfo.config.Noop {
+		run, err := newBackupStore(dfo.config).Begin()
 		if err != nil {
-			simplelog.Fatal.Println(err)
+			log.Fatal(err)
+		}
+		dfo.backupRun = run
+		defer func() {
+			if err := dfo.backupRun.Close(); err != nil {
+				simplelog.Fatal.Println(err)
+			}
+		}()
+	}
+
+	stateStore := newStateStore(dfo.config)
+	prevState, err := stateStore.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := dfo.applyAll(facts); err != nil {
+		log.Fatal(err)
+	}
+
+	if dfo.config.Sync {
+		if err := dfo.syncPrune(prevState.Managed); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if !dfo.config.Noop {
+		managed := make([]string, 0, len(dfo.managed))
+		for target := range dfo.managed {
+			managed = append(managed, target)
+		}
+		if err := stateStore.Save(state.State{Managed: managed}); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := dfo.runLifecycleScripts(); err != nil {
+		if !dfo.config.ContinueOnHookError {
+			log.Fatalf("lifecycle script failed: %v", err)
 		}
+		simplelog.Info.Printf("lifecycle script failed: %v (continuing)", err)
 	}
 }
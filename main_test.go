@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestDotfileEntryUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want dotfileEntry
+	}{
+		{
+			name: "flat string form",
+			yaml: `dst: somefile`,
+			want: dotfileEntry{Src: "somefile"},
+		},
+		{
+			name: "block form",
+			yaml: `dst: {src: somefile, when: "os == 'darwin'", template: true, mode: 0600, before: pre.sh, after: post.sh}`,
+			want: dotfileEntry{Src: "somefile", When: "os == 'darwin'", Template: true, Mode: 0600, Before: "pre.sh", After: "post.sh"},
+		},
+		{
+			name: "block form with only src set",
+			yaml: `dst: {src: somefile}`,
+			want: dotfileEntry{Src: "somefile"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := make(map[string]dotfileEntry)
+			if err := yaml.Unmarshal([]byte(tt.yaml), &m); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			got, ok := m["dst"]
+			if !ok {
+				t.Fatalf("Unmarshal did not produce a %q entry", "dst")
+			}
+			if got != tt.want {
+				t.Fatalf("entry = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Package state tracks which targets dfo currently manages across runs, so a sync can tell
+// apart "no longer in dfo.yaml" from "never managed by dfo in the first place".
+package state
+
+import (
+	"io/ioutil"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// State is the set of targets (paths relative to the user's home directory) dfo managed as of
+// its last run.
+type State struct {
+	Managed []string
+}
+
+// Store loads and saves State.
+type Store interface {
+	Load() (State, error)
+	Save(State) error
+}
+
+// FileStore is a Store backed by a single YAML file. It's not required to exist yet: Load
+// returns a zero State the first time dfo runs.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore returns a Store that persists State to path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) Load() (State, error) {
+	var st State
+
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return st, err
+	}
+
+	err = yaml.Unmarshal(b, &st)
+	return st, err
+}
+
+func (s *FileStore) Save(st State) error {
+	b, err := yaml.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, b, 0644)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/FranGM/dfo/state"
+	"github.com/FranGM/simplelog"
+)
+
+// newStateStore builds the state.Store tracking which targets dfo currently manages, persisted
+// at WorkDir/state.yaml.
+func newStateStore(c dfoConfig) *state.FileStore {
+	return state.NewFileStore(filepath.Join(c.WorkDir, "state.yaml"))
+}
+
+// syncPrune removes symlinks dfo created in a previous run for a target that's no longer in
+// prevManaged's current counterpart (i.e. no longer listed in dfo.yaml, or no longer matching
+// its `when`), restoring the most recent backup for that path if one exists.
+func (dfo *dfoState) syncPrune(prevManaged []string) error {
+	store := newBackupStore(dfo.config)
+
+	for _, target := range prevManaged {
+		if dfo.managed[target] {
+			continue
+		}
+
+		targetPath := filepath.Join(dfo.config.HomeDir, target)
+		fi, err := os.Lstat(targetPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			// No longer a symlink, the user has taken this file over: leave it alone
+			continue
+		}
+
+		simplelog.Info.Printf("Removing stale symlink %q (no longer in dfo.yaml)", target)
+		if dfo.config.Noop {
+			continue
+		}
+
+		if err := os.Remove(targetPath); err != nil {
+			return err
+		}
+		if err := store.RestorePath(target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncPrune(t *testing.T) {
+	home := t.TempDir()
+	config := dfoConfig{HomeDir: home, WorkDir: t.TempDir()}
+
+	// stale-with-backup: dfo-managed symlink for a target that has a prior backup
+	withBackupPath := filepath.Join(home, "stale-with-backup")
+	if err := ioutil.WriteFile(withBackupPath, []byte("original contents"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	run, err := newBackupStore(config).Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if err := run.BackupFile("stale-with-backup", withBackupPath, "/dotfiles/stale-with-backup"); err != nil {
+		t.Fatalf("BackupFile: %v", err)
+	}
+	if err := run.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := os.Remove(withBackupPath); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := os.Symlink("/dotfiles/stale-with-backup", withBackupPath); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	// stale-no-backup: dfo-managed symlink with no prior backup recorded
+	noBackupPath := filepath.Join(home, "stale-no-backup")
+	if err := os.Symlink("/dotfiles/stale-no-backup", noBackupPath); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	// kept: still listed in dfo.yaml this run, must be left alone
+	keptPath := filepath.Join(home, "kept")
+	if err := os.Symlink("/dotfiles/kept", keptPath); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	// user-owned: no longer a symlink, the user has taken it over, must be left alone
+	userOwnedPath := filepath.Join(home, "stale-user-owned")
+	if err := ioutil.WriteFile(userOwnedPath, []byte("user's own contents"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	dfo := &dfoState{config: config, managed: map[string]bool{"kept": true}}
+
+	prevManaged := []string{"stale-with-backup", "stale-no-backup", "kept", "stale-user-owned", "never-existed"}
+	if err := dfo.syncPrune(prevManaged); err != nil {
+		t.Fatalf("syncPrune: %v", err)
+	}
+
+	if fi, err := os.Lstat(withBackupPath); err != nil {
+		t.Fatalf("Lstat(stale-with-backup): %v", err)
+	} else if fi.Mode()&os.ModeSymlink != 0 {
+		t.Fatalf("stale-with-backup is still a symlink, want restored regular file")
+	}
+	if got, err := ioutil.ReadFile(withBackupPath); err != nil {
+		t.Fatalf("ReadFile(stale-with-backup): %v", err)
+	} else if string(got) != "original contents" {
+		t.Fatalf("stale-with-backup contents = %q, want %q", got, "original contents")
+	}
+
+	if _, err := os.Lstat(noBackupPath); !os.IsNotExist(err) {
+		t.Fatalf("stale-no-backup = %v, want removed", err)
+	}
+
+	if target, err := os.Readlink(keptPath); err != nil {
+		t.Fatalf("Readlink(kept): %v", err)
+	} else if target != "/dotfiles/kept" {
+		t.Fatalf("kept symlink target = %q, want unchanged", target)
+	}
+
+	if got, err := ioutil.ReadFile(userOwnedPath); err != nil {
+		t.Fatalf("ReadFile(stale-user-owned): %v", err)
+	} else if string(got) != "user's own contents" {
+		t.Fatalf("stale-user-owned contents = %q, want unchanged", got)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/FranGM/simplelog"
+)
+
+// renderedDir is where rendered templated dotfiles are written to, keyed by their dst path.
+func (dfo *dfoState) renderedDir() string {
+	return filepath.Join(dfo.config.WorkDir, "rendered")
+}
+
+// renderTemplate renders src (relative to the dotfiles repo, unless absolute) as a text/template
+// using facts, writing the result under renderedDir()/dst. The rendered file is only rewritten
+// when its contents changed, tracked through a ".sha256" sidecar checksum, so re-runs only
+// re-render when the template's inputs actually change. The rendered path stays the same, so an
+// existing symlink to it is kept as it is rather than relinked.
+// Returns the absolute path to the rendered file, to be used as the symlink source.
+func (dfo *dfoState) renderTemplate(dst string, src string, facts map[string]interface{}) (string, error) {
+	srcPath := src
+	if !filepath.IsAbs(srcPath) {
+		srcPath = filepath.Join(dfo.config.RepoDir, src)
+	}
+
+	tmpl, err := template.ParseFiles(srcPath)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, facts); err != nil {
+		return "", err
+	}
+
+	renderedPath := filepath.Join(dfo.renderedDir(), dst)
+	sumPath := renderedPath + ".sha256"
+
+	sum := sha256.Sum256(buf.Bytes())
+	newSum := hex.EncodeToString(sum[:])
+
+	if oldSum, err := ioutil.ReadFile(sumPath); err == nil && string(oldSum) == newSum {
+		simplelog.Debug.Printf("Rendered template for %q is unchanged", dst)
+		return renderedPath, nil
+	}
+
+	simplelog.Info.Printf("Rendering %q -> %q", srcPath, renderedPath)
+	if dfo.config.Noop {
+		return renderedPath, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(renderedPath), 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(renderedPath, buf.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	return renderedPath, ioutil.WriteFile(sumPath, []byte(newSum), 0644)
+}
+
+// copyOwned copies src (relative to the dotfiles repo, unless absolute) into renderedDir()/dst
+// and chmods the copy to mode. This gives a `mode` entry without `template: true` a dfo-owned
+// file to chmod and symlink, instead of rewriting permission bits on the git-tracked source file
+// in RepoDir. Like renderTemplate, the copy is only rewritten when its contents changed, tracked
+// through a ".sha256" sidecar checksum.
+// Returns the absolute path to the copy, to be used as the symlink source.
+func (dfo *dfoState) copyOwned(dst string, src string, mode os.FileMode) (string, error) {
+	srcPath := src
+	if !filepath.IsAbs(srcPath) {
+		srcPath = filepath.Join(dfo.config.RepoDir, src)
+	}
+
+	contents, err := ioutil.ReadFile(srcPath)
+	if err != nil {
+		return "", err
+	}
+
+	ownedPath := filepath.Join(dfo.renderedDir(), dst)
+	sumPath := ownedPath + ".sha256"
+
+	sum := sha256.Sum256(contents)
+	newSum := hex.EncodeToString(sum[:])
+
+	if oldSum, err := ioutil.ReadFile(sumPath); err == nil && string(oldSum) == newSum {
+		simplelog.Debug.Printf("Owned copy of %q is unchanged", dst)
+		if !dfo.config.Noop {
+			if err := os.Chmod(ownedPath, mode); err != nil {
+				return "", err
+			}
+		}
+		return ownedPath, nil
+	}
+
+	simplelog.Info.Printf("Copying %q -> %q (mode %v)", srcPath, ownedPath, mode)
+	if dfo.config.Noop {
+		return ownedPath, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ownedPath), 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(ownedPath, contents, mode); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(ownedPath, mode); err != nil {
+		return "", err
+	}
+	return ownedPath, ioutil.WriteFile(sumPath, []byte(newSum), 0644)
+}